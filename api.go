@@ -0,0 +1,84 @@
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// BasicAuth holds the credentials used for HTTP Basic Auth on every
+// request made through an ApiStruct.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// Logger is the interface gopencils uses to log raw request/response
+// dumps. It's satisfied by the standard library's *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// ApiStruct holds the configuration shared by every Resource created
+// from it: the base URL, auth, HTTP client, and the retry, middleware,
+// and codec behavior Resource.do applies to each request.
+type ApiStruct struct {
+	BaseUrl    *url.URL
+	BasicAuth  *BasicAuth
+	Client     *http.Client
+	PathSuffix string
+	Logger     Logger
+	RetryCount int
+
+	// Codec is the default used to marshal payloads and, absent a more
+	// specific match from a response's Content-Type, to decode them. See
+	// ApiStruct.SetCodec.
+	Codec Codec
+
+	// RetryPolicy decides whether and how long to wait between retries.
+	// See ApiStruct.RetryOnMethods. A nil RetryPolicy behaves like
+	// NewDefaultRetryPolicy().
+	RetryPolicy RetryPolicy
+
+	// BeforeRequest and AfterResponse are the registered middleware
+	// chains. See ApiStruct.OnBeforeRequest and ApiStruct.OnAfterResponse.
+	BeforeRequest []RequestMiddleware
+	AfterResponse []ResponseMiddleware
+}
+
+// Api creates an ApiStruct rooted at baseUrl and returns its root
+// Resource. options[0], if given, is a BasicAuth applied to every
+// request made through the returned Resource (and any Resource derived
+// from it via Res/Id).
+func Api(baseUrl string, options ...interface{}) *Resource {
+	parsedUrl, _ := url.Parse(baseUrl)
+
+	api := &ApiStruct{
+		BaseUrl: parsedUrl,
+		Client:  http.DefaultClient,
+	}
+
+	if len(options) > 0 {
+		if auth, ok := options[0].(BasicAuth); ok {
+			api.BasicAuth = &auth
+		}
+	}
+
+	return &Resource{
+		Api:     api,
+		Headers: http.Header{},
+	}
+}
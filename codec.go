@@ -0,0 +1,133 @@
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// Codec marshals outgoing payloads and unmarshals response bodies for a
+// particular wire format, letting a single client talk to REST APIs that
+// don't speak JSON.
+type Codec interface {
+	// ContentType returns the MIME type this Codec produces, sent as the
+	// request's Content-Type header.
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec is the Codec gopencils falls back to when none is configured.
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string { return "application/json" }
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// XMLCodec marshals and unmarshals application/xml payloads.
+type XMLCodec struct{}
+
+func (XMLCodec) ContentType() string { return "application/xml" }
+
+func (XMLCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (XMLCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+// FormCodec marshals a map[string]string or url.Values as
+// application/x-www-form-urlencoded. It can't unmarshal, since a form
+// response doesn't carry structured data back.
+type FormCodec struct{}
+
+func (FormCodec) ContentType() string { return "application/x-www-form-urlencoded" }
+
+func (FormCodec) Marshal(v interface{}) ([]byte, error) {
+	values, err := toURLValues(v)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
+
+func (FormCodec) Unmarshal(data []byte, v interface{}) error {
+	return errors.New("gopencils: FormCodec does not support unmarshaling")
+}
+
+func toURLValues(v interface{}) (url.Values, error) {
+	switch m := v.(type) {
+	case url.Values:
+		return m, nil
+	case map[string]string:
+		values := url.Values{}
+		for k, val := range m {
+			values.Set(k, val)
+		}
+		return values, nil
+	case map[string]interface{}:
+		values := url.Values{}
+		for k, val := range m {
+			values.Set(k, fmt.Sprintf("%v", val))
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("gopencils: FormCodec cannot marshal %T", v)
+	}
+}
+
+// codecForContentType returns the Codec matching the response's
+// Content-Type header, mirroring the content-negotiation resty and
+// similar clients do. Only JSON and XML are recognized (any media type
+// containing "json" or "xml"); for any other media type, including those
+// of FormCodec, MessagePackCodec and ProtobufCodec, and when the header is
+// absent or unparsable, it returns fallback.
+func codecForContentType(header string, fallback Codec) Codec {
+	if header == "" {
+		return fallback
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return fallback
+	}
+	switch {
+	case strings.Contains(mediaType, "json"):
+		return JSONCodec{}
+	case strings.Contains(mediaType, "xml"):
+		return XMLCodec{}
+	}
+	return fallback
+}
+
+// SetCodec sets the default Codec used by every Resource created from this
+// Api, both to marshal outgoing payloads and, absent a more specific match
+// from the response's Content-Type, to decode responses.
+func (a *ApiStruct) SetCodec(codec Codec) *ApiStruct {
+	a.Codec = codec
+	return a
+}
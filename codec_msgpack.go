@@ -0,0 +1,34 @@
+//go:build msgpack
+
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import "github.com/vmihailenco/msgpack/v5"
+
+// MessagePackCodec marshals and unmarshals application/x-msgpack
+// payloads. It's only compiled in with the "msgpack" build tag, so
+// consumers that don't need it aren't forced to vendor the dependency.
+type MessagePackCodec struct{}
+
+func (MessagePackCodec) ContentType() string { return "application/x-msgpack" }
+
+func (MessagePackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (MessagePackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
@@ -0,0 +1,47 @@
+//go:build protobuf
+
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtobufCodec marshals and unmarshals application/x-protobuf payloads.
+// Both v passed to Marshal and v passed to Unmarshal must be a
+// proto.Message. It's only compiled in with the "protobuf" build tag, so
+// consumers that don't need it aren't forced to vendor the dependency.
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) ContentType() string { return "application/x-protobuf" }
+
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("gopencils: ProtobufCodec requires a proto.Message, got %T", v)
+	}
+	return proto.Marshal(msg)
+}
+
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("gopencils: ProtobufCodec requires a proto.Message, got %T", v)
+	}
+	return proto.Unmarshal(data, msg)
+}
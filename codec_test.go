@@ -0,0 +1,96 @@
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type codecTestPayload struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func TestDoRoundTripsJSONByDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"ok"}`))
+	}))
+	defer server.Close()
+
+	root := Api(server.URL)
+	var out codecTestPayload
+	if _, err := root.Res("thing", &out).Get(); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if out.Name != "ok" {
+		t.Errorf("out.Name = %q, want %q", out.Name, "ok")
+	}
+}
+
+func TestDoNegotiatesXMLFromContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte(`<codecTestPayload><name>ok</name></codecTestPayload>`))
+	}))
+	defer server.Close()
+
+	root := Api(server.URL)
+	var out codecTestPayload
+	if _, err := root.Res("thing", &out).Get(); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if out.Name != "ok" {
+		t.Errorf("out.Name = %q, want %q", out.Name, "ok")
+	}
+}
+
+func TestWithCodecOverridesContentNegotiation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the header claims JSON but the body is XML - WithCodec must win
+		// over Content-Type based negotiation regardless.
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`<codecTestPayload><name>ok</name></codecTestPayload>`))
+	}))
+	defer server.Close()
+
+	root := Api(server.URL)
+	var out codecTestPayload
+	res := root.Res("thing", &out).WithCodec(XMLCodec{})
+	if _, err := res.Get(); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if out.Name != "ok" {
+		t.Errorf("out.Name = %q, want %q", out.Name, "ok")
+	}
+}
+
+func TestPostSurfacesMarshalErrorInsteadOfSendingAnEmptyBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("server should not have been called with an unmarshalable payload")
+	}))
+	defer server.Close()
+
+	root := Api(server.URL)
+	root.Api.SetCodec(FormCodec{})
+
+	// FormCodec can only marshal url.Values and string-keyed maps, so a plain
+	// struct must fail instead of silently sending an empty body.
+	_, err := root.Res("thing").Post(codecTestPayload{Name: "ok"})
+	if err == nil {
+		t.Fatal("expected Post to fail because FormCodec can't marshal a struct")
+	}
+}
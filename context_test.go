@@ -0,0 +1,90 @@
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fixedWaitPolicy retries every request, whatever its method, a fixed number of times
+// with a fixed wait, so tests can deterministically land in the middle of
+// a backoff sleep without depending on DefaultRetryPolicy's jitter.
+type fixedWaitPolicy struct {
+	wait time.Duration
+}
+
+func (p fixedWaitPolicy) ShouldRetry(method string, resp *http.Response, err error, attempt int) bool {
+	return attempt < 5
+}
+
+func (p fixedWaitPolicy) WaitTime(resp *http.Response, attempt int) time.Duration {
+	return p.wait
+}
+
+func TestGetCtxPropagatesDeadlineExceeded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	root := Api(server.URL)
+	_, err := root.Res("thing").GetCtx(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("GetCtx err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestContextCancellationDuringRetryBackoffStopsFurtherRequests(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("retry me"))
+	}))
+	defer server.Close()
+
+	root := Api(server.URL)
+	root.Api.RetryCount = 5
+	root.Api.RetryPolicy = fixedWaitPolicy{wait: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := root.Res("thing").GetCtx(ctx)
+	elapsed := time.Since(start)
+
+	if err != context.Canceled {
+		t.Fatalf("GetCtx err = %v, want %v", err, context.Canceled)
+	}
+	if elapsed > 5*time.Second {
+		t.Fatalf("GetCtx took %v, should have returned as soon as the context was canceled mid-backoff", elapsed)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("server received %d requests, want 1 (cancellation should have fired during the first backoff wait, before any retry was sent)", got)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import "net/http"
+
+// RequestMiddleware inspects or mutates an outgoing *http.Request before
+// it is sent. Returning a non-nil error aborts the request; that error is
+// surfaced as the error result of the triggering Get/Post/Put/... call.
+type RequestMiddleware func(*http.Request) error
+
+// ResponseMiddleware runs after a response is received, before its body
+// is inspected for errors or decoded. It receives the Resource alongside
+// the raw *http.Response so it can read status codes and headers, or
+// return a typed error to override gopencils' default behavior of
+// returning the raw body for status codes >= 400.
+type ResponseMiddleware func(*Resource, *http.Response) error
+
+// OnBeforeRequest registers a RequestMiddleware that runs, in
+// registration order, after basic auth and headers are applied and
+// before the request is sent. Use it for things the built-in auth support
+// doesn't cover: OAuth2 token refresh, request signing, or injecting
+// tracing and correlation-ID headers.
+func (a *ApiStruct) OnBeforeRequest(middleware RequestMiddleware) *ApiStruct {
+	a.BeforeRequest = append(a.BeforeRequest, middleware)
+	return a
+}
+
+// OnAfterResponse registers a ResponseMiddleware that runs, in
+// registration order, after a response is received and before gopencils
+// decides how to handle its status code.
+func (a *ApiStruct) OnAfterResponse(middleware ResponseMiddleware) *ApiStruct {
+	a.AfterResponse = append(a.AfterResponse, middleware)
+	return a
+}
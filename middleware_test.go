@@ -0,0 +1,94 @@
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBeforeRequestMiddlewareRunsInRegistrationOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	var order []string
+	root := Api(server.URL)
+	root.Api.OnBeforeRequest(func(req *http.Request) error {
+		order = append(order, "first")
+		return nil
+	})
+	root.Api.OnBeforeRequest(func(req *http.Request) error {
+		order = append(order, "second")
+		return nil
+	})
+
+	var out map[string]interface{}
+	if _, err := root.Res("thing", &out).Get(); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("middleware ran in order %v, want [first second]", order)
+	}
+}
+
+func TestBeforeRequestMiddlewareErrorAbortsRequestBeforeItReachesTheServer(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	wantErr := errors.New("signing failed")
+	root := Api(server.URL)
+	root.Api.OnBeforeRequest(func(req *http.Request) error {
+		return wantErr
+	})
+
+	_, err := root.Res("thing").Get()
+	if err != wantErr {
+		t.Fatalf("Get err = %v, want %v", err, wantErr)
+	}
+	if called {
+		t.Error("server should not have been called when BeforeRequest middleware errors")
+	}
+}
+
+func TestAfterResponseMiddlewareErrorClosesTheBodyBeforeReturning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	wantErr := errors.New("rejected by middleware")
+	root := Api(server.URL)
+	root.Api.OnAfterResponse(func(res *Resource, resp *http.Response) error {
+		return wantErr
+	})
+
+	res, err := root.Res("thing").Get()
+	if err != wantErr {
+		t.Fatalf("Get err = %v, want %v", err, wantErr)
+	}
+	if res.Raw == nil {
+		t.Fatal("expected Raw to be set before AfterResponse runs")
+	}
+	if _, err := res.Raw.Body.Read(make([]byte, 1)); err == nil {
+		t.Error("expected resp.Body to already be closed after an AfterResponse error")
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// NextPageFunc inspects a fetched page and returns the URL of the next
+// page, or done=true when there are no more pages. Use it for APIs that
+// embed a pagination cursor in the response body (e.g. "next_cursor")
+// instead of a Link header.
+type NextPageFunc func(page *Resource) (nextURL string, done bool)
+
+// PaginateOptions configures a PageIterator.
+type PaginateOptions struct {
+	// NextPageFunc, when set, overrides Link-header based pagination with
+	// a body-driven cursor.
+	NextPageFunc NextPageFunc
+}
+
+// PageIterator walks a paginated collection one page at a time, following
+// RFC 5988 Link headers by default or a caller-supplied NextPageFunc. It
+// reuses the originating Resource's Api, so every page shares the same
+// auth, middleware, and retry configuration.
+type PageIterator struct {
+	current *Resource
+	opts    PaginateOptions
+	nextURL string
+	fetched bool
+	done    bool
+	err     error
+}
+
+// Paginate returns a PageIterator seeded at r, which follows subsequent
+// pages according to opts.
+func (r *Resource) Paginate(opts PaginateOptions) *PageIterator {
+	return &PageIterator{current: r, opts: opts}
+}
+
+// Next fetches the next page, returning false once pagination is
+// exhausted or an error occurs; call Err afterwards to tell the two
+// apart.
+func (it *PageIterator) Next(ctx context.Context) bool {
+	if it.done || it.err != nil {
+		return false
+	}
+
+	if !it.fetched {
+		it.fetched = true
+		if _, err := it.current.WithContext(ctx).Get(); err != nil {
+			it.err = err
+			return false
+		}
+	} else {
+		if it.nextURL == "" {
+			it.done = true
+			return false
+		}
+		page := it.current.nextPageResource(it.nextURL)
+		if _, err := page.WithContext(ctx).Get(); err != nil {
+			it.err = err
+			return false
+		}
+		it.current = page
+	}
+
+	next, done := it.nextPage()
+	it.nextURL = next
+	if done {
+		it.done = true
+	}
+	return true
+}
+
+// Page returns the most recently fetched page.
+func (it *PageIterator) Page() *Resource {
+	return it.current
+}
+
+// Err returns the error, if any, that stopped iteration early.
+func (it *PageIterator) Err() error {
+	return it.err
+}
+
+func (it *PageIterator) nextPage() (nextURL string, done bool) {
+	if it.opts.NextPageFunc != nil {
+		return it.opts.NextPageFunc(it.current)
+	}
+	if it.current.Raw == nil {
+		return "", true
+	}
+	return parseLinkHeaderNext(it.current.Raw.Header.Get("Link"))
+}
+
+// nextPageResource builds the Resource for the next page at absoluteURL,
+// sharing r's Api (and therefore its auth, middleware, and retry
+// configuration) and Codec, and allocating a fresh Response of the same
+// type as r.Response so each page decodes independently.
+func (r *Resource) nextPageResource(absoluteURL string) *Resource {
+	header := r.Headers
+	if header == nil {
+		header = http.Header{}
+	}
+	return &Resource{
+		Api:         r.Api,
+		absoluteURL: absoluteURL,
+		Headers:     header,
+		Logger:      r.Logger,
+		Codec:       r.Codec,
+		Response:    newLike(r.Response),
+	}
+}
+
+// newLike allocates a new zero value of the same type as template and
+// returns a pointer to it, so it can be passed to Codec.Unmarshal. It
+// expects template to itself be a pointer, as Response fields must be to
+// be decoded into.
+func newLike(template interface{}) interface{} {
+	if template == nil {
+		return nil
+	}
+	t := reflect.TypeOf(template)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return reflect.New(t).Interface()
+}
+
+// All drains every page of r's paginated collection (per opts) into dst,
+// a pointer to a slice whose element type matches what each page's
+// Response decodes into - for example a *[]Issue for a Resource whose
+// Response is a *[]Issue. Each page's items are appended to dst in turn.
+func (r *Resource) All(ctx context.Context, opts PaginateOptions, dst interface{}) error {
+	dstVal := reflect.ValueOf(dst)
+	if dstVal.Kind() != reflect.Ptr || dstVal.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("gopencils: All requires a pointer to a slice, got %T", dst)
+	}
+	sliceVal := dstVal.Elem()
+
+	it := r.Paginate(opts)
+	for it.Next(ctx) {
+		page := reflect.ValueOf(it.Page().Response)
+		if page.Kind() == reflect.Ptr {
+			page = page.Elem()
+		}
+		if page.Kind() != reflect.Slice {
+			continue
+		}
+		if !page.Type().AssignableTo(sliceVal.Type()) {
+			return fmt.Errorf("gopencils: All got a page of type %s, not assignable to dst's %s", page.Type(), sliceVal.Type())
+		}
+		sliceVal.Set(reflect.AppendSlice(sliceVal, page))
+	}
+	return it.Err()
+}
+
+// parseLinkHeaderNext extracts the rel="next" URI-reference from an RFC
+// 5988 Link header, tokenizing on commas that fall outside the <...>
+// delimiters so commas inside a URI's query string don't split it early.
+func parseLinkHeaderNext(header string) (next string, done bool) {
+	if header == "" {
+		return "", true
+	}
+	for _, part := range splitLinkHeader(header) {
+		part = strings.TrimSpace(part)
+		start := strings.Index(part, "<")
+		end := strings.Index(part, ">")
+		if start == -1 || end == -1 || end <= start {
+			continue
+		}
+		if strings.Contains(part[end:], `rel="next"`) {
+			return part[start+1 : end], false
+		}
+	}
+	return "", true
+}
+
+// splitLinkHeader splits a Link header on commas that aren't inside a
+// <...> URI-reference.
+func splitLinkHeader(header string) []string {
+	var parts []string
+	depth := 0
+	last := 0
+	for i, r := range header {
+		switch r {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, header[last:i])
+				last = i + 1
+			}
+		}
+	}
+	parts = append(parts, header[last:])
+	return parts
+}
@@ -0,0 +1,87 @@
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import "testing"
+
+func TestParseLinkHeaderNext(t *testing.T) {
+	cases := []struct {
+		name     string
+		header   string
+		wantNext string
+		wantDone bool
+	}{
+		{
+			name:     "empty header is done",
+			header:   "",
+			wantNext: "",
+			wantDone: true,
+		},
+		{
+			name:     "github style next and last",
+			header:   `<https://api.github.com/resource?page=2>; rel="next", <https://api.github.com/resource?page=5>; rel="last"`,
+			wantNext: "https://api.github.com/resource?page=2",
+			wantDone: false,
+		},
+		{
+			name:     "rel=next after other params, commas inside query string",
+			header:   `<https://api.example.com/r?a=1,2&page=3>; type="application/json"; rel="next"`,
+			wantNext: "https://api.example.com/r?a=1,2&page=3",
+			wantDone: false,
+		},
+		{
+			name:     "only rel=prev present means done",
+			header:   `<https://api.example.com/r?page=1>; rel="prev"`,
+			wantNext: "",
+			wantDone: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			next, done := parseLinkHeaderNext(c.header)
+			if next != c.wantNext || done != c.wantDone {
+				t.Errorf("parseLinkHeaderNext(%q) = (%q, %v), want (%q, %v)", c.header, next, done, c.wantNext, c.wantDone)
+			}
+		})
+	}
+}
+
+func TestSplitLinkHeaderIgnoresCommasInsideAngleBrackets(t *testing.T) {
+	header := `<https://api.example.com/r?a=1,2>; rel="next", <https://api.example.com/r?page=1>; rel="prev"`
+
+	parts := splitLinkHeader(header)
+	if len(parts) != 2 {
+		t.Fatalf("splitLinkHeader(%q) = %d parts, want 2: %#v", header, len(parts), parts)
+	}
+}
+
+func TestNewLikeAllocatesSameUnderlyingType(t *testing.T) {
+	type issue struct{ ID int }
+	template := &[]issue{{ID: 1}}
+
+	got := newLike(template)
+
+	typed, ok := got.(*[]issue)
+	if !ok {
+		t.Fatalf("newLike(%T) = %T, want *[]issue", template, got)
+	}
+	if typed == template {
+		t.Error("newLike should allocate a new value, not reuse the template's pointer")
+	}
+	if len(*typed) != 0 {
+		t.Errorf("newLike should return a zero value, got %v", *typed)
+	}
+}
@@ -12,15 +12,14 @@
 // License for the specific language governing permissions and limitations
 // under the License.
 
-// Gopencils is a Golang REST Client with which you can easily consume REST API's. Supported Response formats: JSON
+// Gopencils is a Golang REST Client with which you can easily consume REST API's. Supported formats: JSON and XML for payloads and responses, form-urlencoded for payloads only, and, via build tags, MessagePack and Protobuf.
 package gopencils
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"io"
-	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -41,6 +40,11 @@ type Resource struct {
 	Response    interface{}
 	Raw         *http.Response
 	Logger      Logger
+	Codec       Codec
+	streaming   bool
+	ctx         context.Context
+	absoluteURL string
+	payloadErr  error
 }
 
 // Creates a new Resource.
@@ -107,6 +111,44 @@ func (r *Resource) Id(options ...interface{}) *Resource {
 	return r
 }
 
+// WithCodec overrides the Codec used to marshal the payload and unmarshal
+// the response for this Resource, taking precedence over Api's default
+// Codec and Content-Type based negotiation.
+func (r *Resource) WithCodec(codec Codec) *Resource {
+	r.Codec = codec
+	return r
+}
+
+// codec returns the Codec this Resource should use: its own override, the
+// Api's configured default, or plain JSON if neither was set.
+func (r *Resource) codec() Codec {
+	if r.Codec != nil {
+		return r.Codec
+	}
+	if r.Api != nil && r.Api.Codec != nil {
+		return r.Api.Codec
+	}
+	return JSONCodec{}
+}
+
+// WithContext attaches ctx to the Resource so that the request built by
+// the next Get/Post/Put/... call - and its retry backoff sleeps - can be
+// cancelled or bounded by a deadline. Without a call to WithContext,
+// requests behave as if bound to context.Background().
+func (r *Resource) WithContext(ctx context.Context) *Resource {
+	r.ctx = ctx
+	return r
+}
+
+// context returns the Resource's context, defaulting to
+// context.Background() when WithContext hasn't been called.
+func (r *Resource) context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
+}
+
 // Sets QueryValues for current Resource
 func (r *Resource) SetQuery(querystring map[string]string) *Resource {
 	r.QueryValues = make(url.Values)
@@ -129,6 +171,12 @@ func (r *Resource) Get(options ...interface{}) (*Resource, error) {
 	return r.do("GET")
 }
 
+// GetCtx is like Get, but binds ctx to the request so it can be
+// cancelled or bounded by a deadline.
+func (r *Resource) GetCtx(ctx context.Context, options ...interface{}) (*Resource, error) {
+	return r.WithContext(ctx).Get(options...)
+}
+
 // Performs a HEAD request on given Resource
 // Accepts map[string]string as parameter, will be used as querystring.
 func (r *Resource) Head(options ...interface{}) (*Resource, error) {
@@ -142,6 +190,12 @@ func (r *Resource) Head(options ...interface{}) (*Resource, error) {
 	return r.do("HEAD")
 }
 
+// HeadCtx is like Head, but binds ctx to the request so it can be
+// cancelled or bounded by a deadline.
+func (r *Resource) HeadCtx(ctx context.Context, options ...interface{}) (*Resource, error) {
+	return r.WithContext(ctx).Head(options...)
+}
+
 // Performs a PUT request on given Resource.
 // Accepts interface{} as parameter, will be used as payload.
 func (r *Resource) Put(options ...interface{}) (*Resource, error) {
@@ -151,6 +205,12 @@ func (r *Resource) Put(options ...interface{}) (*Resource, error) {
 	return r.do("PUT")
 }
 
+// PutCtx is like Put, but binds ctx to the request so it can be
+// cancelled or bounded by a deadline.
+func (r *Resource) PutCtx(ctx context.Context, options ...interface{}) (*Resource, error) {
+	return r.WithContext(ctx).Put(options...)
+}
+
 // Performs a POST request on given Resource.
 // Accepts interface{} as parameter, will be used as payload.
 func (r *Resource) Post(options ...interface{}) (*Resource, error) {
@@ -160,6 +220,12 @@ func (r *Resource) Post(options ...interface{}) (*Resource, error) {
 	return r.do("POST")
 }
 
+// PostCtx is like Post, but binds ctx to the request so it can be
+// cancelled or bounded by a deadline.
+func (r *Resource) PostCtx(ctx context.Context, options ...interface{}) (*Resource, error) {
+	return r.WithContext(ctx).Post(options...)
+}
+
 // Performs a Delete request on given Resource.
 // Accepts map[string]string as parameter, will be used as querystring.
 func (r *Resource) Delete(options ...interface{}) (*Resource, error) {
@@ -173,6 +239,12 @@ func (r *Resource) Delete(options ...interface{}) (*Resource, error) {
 	return r.do("DELETE")
 }
 
+// DeleteCtx is like Delete, but binds ctx to the request so it can be
+// cancelled or bounded by a deadline.
+func (r *Resource) DeleteCtx(ctx context.Context, options ...interface{}) (*Resource, error) {
+	return r.WithContext(ctx).Delete(options...)
+}
+
 // Performs a Delete request on given Resource.
 // Accepts map[string]string as parameter, will be used as querystring.
 func (r *Resource) Options(options ...interface{}) (*Resource, error) {
@@ -186,6 +258,12 @@ func (r *Resource) Options(options ...interface{}) (*Resource, error) {
 	return r.do("OPTIONS")
 }
 
+// OptionsCtx is like Options, but binds ctx to the request so it can be
+// cancelled or bounded by a deadline.
+func (r *Resource) OptionsCtx(ctx context.Context, options ...interface{}) (*Resource, error) {
+	return r.WithContext(ctx).Options(options...)
+}
+
 // Performs a PATCH request on given Resource.
 // Accepts interface{} as parameter, will be used as payload.
 func (r *Resource) Patch(options ...interface{}) (*Resource, error) {
@@ -195,9 +273,31 @@ func (r *Resource) Patch(options ...interface{}) (*Resource, error) {
 	return r.do("PATCH")
 }
 
-// Main method, opens the connection, sets basic auth, applies headers,
-// parses response json.
-func (r *Resource) do(method string) (*Resource, error) {
+// PatchCtx is like Patch, but binds ctx to the request so it can be
+// cancelled or bounded by a deadline.
+func (r *Resource) PatchCtx(ctx context.Context, options ...interface{}) (*Resource, error) {
+	return r.WithContext(ctx).Patch(options...)
+}
+
+// drainAndClose drains and closes resp's body, if any, so the underlying
+// connection can be reused. It's a no-op for a nil response or body, and
+// safe to call on a response that's about to be discarded or replaced.
+func drainAndClose(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// requestURL builds the URL for this Resource: absoluteURL verbatim when
+// set (used by pagination to follow a Link header's full next-page URL),
+// or the usual BaseUrl + Url + PathSuffix join otherwise.
+func (r *Resource) requestURL() string {
+	if r.absoluteURL != "" {
+		return r.absoluteURL
+	}
+
 	url := *r.Api.BaseUrl
 	if len(url.Path) > 0 {
 		url.Path += "/" + r.Url
@@ -209,7 +309,18 @@ func (r *Resource) do(method string) (*Resource, error) {
 	}
 
 	url.RawQuery = r.QueryValues.Encode()
-	req, err := http.NewRequest(method, url.String(), r.Payload)
+	return url.String()
+}
+
+// Main method, opens the connection, sets basic auth, applies headers,
+// decodes the response with the Resource's Codec (or, absent a WithCodec
+// override, one chosen from the response's Content-Type).
+func (r *Resource) do(method string) (*Resource, error) {
+	if r.payloadErr != nil {
+		return r, r.payloadErr
+	}
+
+	req, err := http.NewRequestWithContext(r.context(), method, r.requestURL(), r.Payload)
 	if err != nil {
 		return r, err
 	}
@@ -224,6 +335,12 @@ func (r *Resource) do(method string) (*Resource, error) {
 		}
 	}
 
+	for _, middleware := range r.Api.BeforeRequest {
+		if err := middleware(req); err != nil {
+			return r, err
+		}
+	}
+
 	if r.Logger != nil {
 		dump, err := httputil.DumpRequest(req, true)
 		if err != nil {
@@ -248,34 +365,55 @@ func (r *Resource) do(method string) (*Resource, error) {
 	}
 	r.Headers.Set("X-Total-Retries", strconv.Itoa(0))
 
+	policy := r.Api.RetryPolicy
+	if policy == nil {
+		policy = NewDefaultRetryPolicy()
+	}
+
 	resp, err := r.Api.Client.Do(req)
 	totalRetries := 0
 
-	if err != nil {
-		for i := 0; i < r.Api.RetryCount; i++ {
-			if i > 0 {
-				time.Sleep(time.Duration(math.Pow(2, float64(i))) * time.Second) // exponential backoff - in case requested resource is busy
-			}
-			if len(requestBody) > 0 {
-				req.Body = io.NopCloser(bytes.NewReader(requestBody))
-				req.ContentLength = int64(len(requestBody))
-			}
-			resp, err = r.Api.Client.Do(req)
-			totalRetries++
-			
-			if err == nil && (resp == nil || resp.StatusCode < 500) {
-				break
+	for i := 0; policy.ShouldRetry(method, resp, err, i) && i < r.Api.RetryCount; i++ {
+		wait := policy.WaitTime(resp, i)
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-r.context().Done():
+				timer.Stop()
+				drainAndClose(resp)
+				return r, r.context().Err()
 			}
 		}
-		r.Headers.Set("X-Total-Retries", strconv.Itoa(totalRetries))
-		if err != nil {
-			return r, err
+		if len(requestBody) > 0 {
+			req.Body = io.NopCloser(bytes.NewReader(requestBody))
+			req.ContentLength = int64(len(requestBody))
 		}
+		// drain and close the previous attempt's response so the
+		// connection can be reused - we're about to overwrite resp and
+		// this is our last chance to do so
+		drainAndClose(resp)
+		resp, err = r.Api.Client.Do(req)
+		totalRetries++
+	}
+
+	r.Headers.Set("X-Total-Retries", strconv.Itoa(totalRetries))
+	if err != nil {
+		if ctxErr := r.context().Err(); ctxErr != nil {
+			return r, ctxErr
+		}
+		return r, err
 	}
 
 	r.Raw = resp
 
-	defer resp.Body.Close()
+	if !r.streaming {
+		// the caller (Stream/SaveToFile) owns resp.Body when streaming and
+		// is responsible for closing it; otherwise we must close it no
+		// matter which return path below is taken, including one taken by
+		// an AfterResponse middleware.
+		defer resp.Body.Close()
+	}
 
 	if r.Logger != nil {
 		dump, err := httputil.DumpResponse(resp, true)
@@ -285,7 +423,22 @@ func (r *Resource) do(method string) (*Resource, error) {
 			r.Logger.Printf("%s", string(dump))
 		}
 	}
-	
+
+	for _, middleware := range r.Api.AfterResponse {
+		if err := middleware(r, resp); err != nil {
+			if r.streaming {
+				// Stream never gets to run its handler on this path, so
+				// it can't close the body itself
+				resp.Body.Close()
+			}
+			return r, err
+		}
+	}
+
+	if r.streaming {
+		return r, nil
+	}
+
 	if resp.StatusCode >= 400 {
 		bodyBytes, err := io.ReadAll(resp.Body)
 		if err != nil {
@@ -303,7 +456,21 @@ func (r *Resource) do(method string) (*Resource, error) {
 		return r, nil
 	}
 
-	err = json.NewDecoder(resp.Body).Decode(r.Response)
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return r, err
+	}
+	if len(bodyBytes) == 0 {
+		return r, nil
+	}
+
+	codec := r.codec()
+	if r.Codec == nil {
+		// only let the response's Content-Type pick a different codec when
+		// the caller didn't explicitly choose one via WithCodec.
+		codec = codecForContentType(r.Raw.Header.Get("Content-Type"), codec)
+	}
+	err = codec.Unmarshal(bodyBytes, r.Response)
 	if err != nil {
 		return r, err
 	}
@@ -313,9 +480,16 @@ func (r *Resource) do(method string) (*Resource, error) {
 
 // Sets Payload for current Resource
 func (r *Resource) SetPayload(args interface{}) io.Reader {
-	var b []byte
-	b, _ = json.Marshal(args)
-	r.SetHeader("Content-Type", "application/json")
+	codec := r.codec()
+	b, err := codec.Marshal(args)
+	if err != nil {
+		// surfaced by do(), which refuses to send a request built from a
+		// payload that failed to marshal instead of silently sending an
+		// empty body
+		r.payloadErr = err
+		return nil
+	}
+	r.SetHeader("Content-Type", codec.ContentType())
 	return bytes.NewBuffer(b)
 }
 
@@ -0,0 +1,151 @@
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy decides whether a request attempt should be retried and how
+// long to wait before the next one.
+type RetryPolicy interface {
+	// ShouldRetry is called after an attempt with the transport error (if
+	// any) and the response (if one was received, possibly nil), and
+	// reports whether another attempt should be made. attempt is 0 on the
+	// first retry.
+	ShouldRetry(method string, resp *http.Response, err error, attempt int) bool
+	// WaitTime returns how long to sleep before the given attempt.
+	WaitTime(resp *http.Response, attempt int) time.Duration
+}
+
+// defaultRetryableStatusCodes are the status codes DefaultRetryPolicy
+// treats as transient and worth retrying.
+var defaultRetryableStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true, // 429
+	http.StatusBadGateway:         true, // 502
+	http.StatusServiceUnavailable: true, // 503
+	http.StatusGatewayTimeout:     true, // 504
+}
+
+// idempotentMethods are retried without requiring explicit opt-in, since
+// replaying them is always safe.
+var idempotentMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"OPTIONS": true,
+	"DELETE":  true,
+}
+
+// DefaultRetryPolicy retries transport errors and a configurable set of
+// HTTP status codes, backing off exponentially with full jitter capped at
+// MaxWaitTime, and honors the server's Retry-After header when present.
+// Non-idempotent methods (POST, PUT, PATCH) are skipped unless added via
+// ApiStruct.RetryOnMethods.
+type DefaultRetryPolicy struct {
+	// MaxWaitTime caps the backoff computed for any single attempt.
+	MaxWaitTime time.Duration
+	// RetryableStatusCodes overrides defaultRetryableStatusCodes when set.
+	RetryableStatusCodes map[int]bool
+	// ExtraMethods lists additional HTTP methods that may be retried
+	// despite not being idempotent by default.
+	ExtraMethods map[string]bool
+}
+
+// NewDefaultRetryPolicy returns a DefaultRetryPolicy with sane defaults.
+func NewDefaultRetryPolicy() *DefaultRetryPolicy {
+	return &DefaultRetryPolicy{
+		MaxWaitTime: 30 * time.Second,
+	}
+}
+
+func (p *DefaultRetryPolicy) ShouldRetry(method string, resp *http.Response, err error, attempt int) bool {
+	if !idempotentMethods[method] && !p.ExtraMethods[strings.ToUpper(method)] {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	if resp == nil {
+		return false
+	}
+	codes := p.RetryableStatusCodes
+	if codes == nil {
+		codes = defaultRetryableStatusCodes
+	}
+	return codes[resp.StatusCode]
+}
+
+func (p *DefaultRetryPolicy) WaitTime(resp *http.Response, attempt int) time.Duration {
+	if resp != nil {
+		if wait, ok := retryAfter(resp); ok {
+			return capWait(wait, p.MaxWaitTime)
+		}
+	}
+	backoff := time.Duration(math.Pow(2, float64(attempt))) * time.Second
+	jittered := time.Duration(rand.Int63n(int64(backoff) + 1)) // full jitter
+	return capWait(jittered, p.MaxWaitTime)
+}
+
+func capWait(wait, max time.Duration) time.Duration {
+	if max > 0 && wait > max {
+		return max
+	}
+	return wait
+}
+
+// retryAfter parses the response's Retry-After header, which per RFC 7231
+// is either a number of delta-seconds or an HTTP-date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if when, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(when); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// RetryOnMethods opts additional, non-idempotent HTTP methods (e.g.
+// "POST") into the default retry policy. Idempotent methods are always
+// eligible. It's a no-op if ApiStruct.RetryPolicy has been replaced with a
+// custom implementation.
+func (a *ApiStruct) RetryOnMethods(methods ...string) *ApiStruct {
+	if a.RetryPolicy == nil {
+		a.RetryPolicy = NewDefaultRetryPolicy()
+	}
+	policy, ok := a.RetryPolicy.(*DefaultRetryPolicy)
+	if !ok {
+		return a
+	}
+	if policy.ExtraMethods == nil {
+		policy.ExtraMethods = make(map[string]bool)
+	}
+	for _, method := range methods {
+		policy.ExtraMethods[strings.ToUpper(method)] = true
+	}
+	return a
+}
@@ -0,0 +1,110 @@
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDefaultRetryPolicyShouldRetry(t *testing.T) {
+	policy := NewDefaultRetryPolicy()
+
+	cases := []struct {
+		name   string
+		method string
+		resp   *http.Response
+		err    error
+		want   bool
+	}{
+		{"GET transport error retries", "GET", nil, http.ErrHandlerTimeout, true},
+		{"GET 503 retries", "GET", &http.Response{StatusCode: 503}, nil, true},
+		{"GET 429 retries", "GET", &http.Response{StatusCode: 429}, nil, true},
+		{"GET 200 does not retry", "GET", &http.Response{StatusCode: 200}, nil, false},
+		{"GET 404 does not retry", "GET", &http.Response{StatusCode: 404}, nil, false},
+		{"POST 503 does not retry by default", "POST", &http.Response{StatusCode: 503}, nil, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := policy.ShouldRetry(c.method, c.resp, c.err, 0)
+			if got != c.want {
+				t.Errorf("ShouldRetry(%q, %+v, %v) = %v, want %v", c.method, c.resp, c.err, got, c.want)
+			}
+		})
+	}
+}
+
+func TestDefaultRetryPolicyRetryOnMethodsOptsIn(t *testing.T) {
+	api := &ApiStruct{}
+	api.RetryOnMethods("POST")
+
+	policy, ok := api.RetryPolicy.(*DefaultRetryPolicy)
+	if !ok {
+		t.Fatalf("RetryOnMethods did not install a *DefaultRetryPolicy on a fresh ApiStruct, got %T", api.RetryPolicy)
+	}
+
+	resp := &http.Response{StatusCode: 503}
+	if !policy.ShouldRetry("POST", resp, nil, 0) {
+		t.Error("expected POST to be retried after RetryOnMethods(\"POST\")")
+	}
+	if !policy.ShouldRetry("GET", resp, nil, 0) {
+		t.Error("expected GET to remain retryable")
+	}
+}
+
+func TestDefaultRetryPolicyWaitTimeHonorsRetryAfterSeconds(t *testing.T) {
+	policy := NewDefaultRetryPolicy()
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"2"}}}
+
+	wait := policy.WaitTime(resp, 0)
+	if wait != 2*time.Second {
+		t.Errorf("WaitTime = %v, want 2s", wait)
+	}
+}
+
+func TestDefaultRetryPolicyWaitTimeHonorsRetryAfterHTTPDate(t *testing.T) {
+	policy := NewDefaultRetryPolicy()
+	future := time.Now().Add(5 * time.Second).UTC().Format(http.TimeFormat)
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{future}}}
+
+	wait := policy.WaitTime(resp, 0)
+	if wait <= 0 || wait > 6*time.Second {
+		t.Errorf("WaitTime = %v, want roughly 5s", wait)
+	}
+}
+
+func TestDefaultRetryPolicyWaitTimeCapsAtMaxWaitTime(t *testing.T) {
+	policy := &DefaultRetryPolicy{MaxWaitTime: time.Second}
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"30"}}}
+
+	wait := policy.WaitTime(resp, 0)
+	if wait != time.Second {
+		t.Errorf("WaitTime = %v, want capped at 1s", wait)
+	}
+}
+
+func TestDefaultRetryPolicyWaitTimeBacksOffWithoutRetryAfter(t *testing.T) {
+	policy := &DefaultRetryPolicy{MaxWaitTime: time.Minute}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		wait := policy.WaitTime(nil, attempt)
+		maxForAttempt := time.Duration(1<<uint(attempt)) * time.Second
+		if wait < 0 || wait > maxForAttempt {
+			t.Errorf("WaitTime(nil, %d) = %v, want in [0, %v]", attempt, wait, maxForAttempt)
+		}
+	}
+}
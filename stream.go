@@ -0,0 +1,98 @@
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"os"
+)
+
+// SetBody sets an arbitrary io.Reader as the request Payload, bypassing
+// the configured Codec entirely. Use it for uploads where the caller
+// already has an encoded body, such as a file handle or a multipart body
+// built by SetMultipartFields.
+func (r *Resource) SetBody(body io.Reader, contentType string) *Resource {
+	r.Payload = body
+	r.SetHeader("Content-Type", contentType)
+	return r
+}
+
+// SetMultipartFields builds a multipart/form-data body out of plain form
+// fields and named file parts, and sets it as the request Payload with
+// the correct Content-Type, boundary included. Use it for endpoints that
+// accept file uploads, such as avatars or build artifacts.
+func (r *Resource) SetMultipartFields(fields map[string]string, files map[string]io.Reader) (*Resource, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return r, err
+		}
+	}
+
+	for name, file := range files {
+		part, err := writer.CreateFormFile(name, name)
+		if err != nil {
+			return r, err
+		}
+		if _, err := io.Copy(part, file); err != nil {
+			return r, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return r, err
+	}
+
+	r.Payload = body
+	r.SetHeader("Content-Type", writer.FormDataContentType())
+	return r, nil
+}
+
+// Stream performs a GET request and passes the raw response body to
+// handler instead of decoding it through the configured Codec, closing
+// the body once handler returns. Use it for large or binary responses,
+// such as artifact downloads or log tails, that aren't structured
+// payloads.
+func (r *Resource) Stream(handler func(io.Reader) error) error {
+	r.streaming = true
+	defer func() { r.streaming = false }()
+
+	if _, err := r.do("GET"); err != nil {
+		return err
+	}
+	if r.Raw == nil {
+		return nil
+	}
+	defer r.Raw.Body.Close()
+	return handler(r.Raw.Body)
+}
+
+// SaveToFile performs a GET request and streams the response body
+// directly to the file at path, without attempting to decode it.
+func (r *Resource) SaveToFile(path string) error {
+	return r.Stream(func(body io.Reader) error {
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		_, err = io.Copy(out, body)
+		return err
+	})
+}
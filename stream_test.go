@@ -0,0 +1,105 @@
+// Copyright 2014 Vadim Kravcenko
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package gopencils
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetMultipartFieldsSendsFieldsAndFiles(t *testing.T) {
+	var gotField, gotFile string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("ParseMultipartForm failed: %v", err)
+			return
+		}
+		gotField = r.FormValue("title")
+		file, _, err := r.FormFile("avatar")
+		if err != nil {
+			t.Errorf("FormFile failed: %v", err)
+			return
+		}
+		defer file.Close()
+		content, _ := io.ReadAll(file)
+		gotFile = string(content)
+	}))
+	defer server.Close()
+
+	root := Api(server.URL)
+	res, err := root.Res("thing").SetMultipartFields(
+		map[string]string{"title": "a build artifact"},
+		map[string]io.Reader{"avatar": strings.NewReader("binary content")},
+	)
+	if err != nil {
+		t.Fatalf("SetMultipartFields failed: %v", err)
+	}
+	if _, err := res.Post(); err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	if gotField != "a build artifact" {
+		t.Errorf("field title = %q, want %q", gotField, "a build artifact")
+	}
+	if gotFile != "binary content" {
+		t.Errorf("file avatar = %q, want %q", gotFile, "binary content")
+	}
+}
+
+func TestStreamPassesRawBodyToHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("raw stream body"))
+	}))
+	defer server.Close()
+
+	root := Api(server.URL)
+	var got bytes.Buffer
+	err := root.Res("thing").Stream(func(body io.Reader) error {
+		_, err := io.Copy(&got, body)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if got.String() != "raw stream body" {
+		t.Errorf("streamed body = %q, want %q", got.String(), "raw stream body")
+	}
+}
+
+func TestSaveToFileWritesResponseBodyToDisk(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("file contents"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "downloaded")
+	root := Api(server.URL)
+	if err := root.Res("thing").SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading saved file failed: %v", err)
+	}
+	if string(content) != "file contents" {
+		t.Errorf("saved file contents = %q, want %q", content, "file contents")
+	}
+}